@@ -0,0 +1,73 @@
+package filepath
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fieldByPath walks obj via reflection following a dotted path such as
+// "spec.replicas", matching struct field names case-insensitively so callers
+// can use the same lowercase path conventions the Kubernetes API uses. It
+// backs the /scale subresource, which has to read and write a field on an
+// otherwise-arbitrary resource type without that type implementing any
+// interface for it.
+func fieldByPath(obj interface{}, path string) (reflect.Value, error) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, fmt.Errorf("path %q: nil pointer", path)
+		}
+		v = v.Elem()
+	}
+	for _, part := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("path %q: %q is not a struct field", path, part)
+		}
+		v = v.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, part)
+		})
+		if !v.IsValid() {
+			return reflect.Value{}, fmt.Errorf("path %q: no field matching %q", path, part)
+		}
+	}
+	return v, nil
+}
+
+// topLevelField returns obj's direct struct field matching name
+// case-insensitively. It backs the /status subresource's split between
+// .status and everything else.
+func topLevelField(obj runtime.Object, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	f := v.FieldByNameFunc(func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+	return f, f.IsValid()
+}
+
+// restoreField copies src's name field onto dst, silently doing nothing for
+// types with no such field. The main resource's Update uses this to pin
+// .status to its previous value, so only the /status subresource can change
+// it.
+func restoreField(dst, src runtime.Object, name string) {
+	df, ok := topLevelField(dst, name)
+	if !ok || !df.CanSet() {
+		return
+	}
+	sf, ok := topLevelField(src, name)
+	if !ok {
+		return
+	}
+	df.Set(sf)
+}
@@ -0,0 +1,80 @@
+package filepath
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type fieldpathTestSpec struct {
+	Replicas int32
+}
+
+type fieldpathTestStatus struct {
+	Replicas int32
+}
+
+type fieldpathTestObject struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+	Spec   fieldpathTestSpec
+	Status fieldpathTestStatus
+}
+
+func (o *fieldpathTestObject) DeepCopyObject() runtime.Object {
+	cp := *o
+	return &cp
+}
+
+func TestFieldByPath(t *testing.T) {
+	obj := &fieldpathTestObject{Spec: fieldpathTestSpec{Replicas: 3}}
+
+	v, err := fieldByPath(obj, "spec.replicas")
+	if err != nil {
+		t.Fatalf("fieldByPath: %v", err)
+	}
+	if v.Int() != 3 {
+		t.Fatalf("expected 3, got %d", v.Int())
+	}
+
+	if !v.CanSet() {
+		t.Fatalf("expected a settable field")
+	}
+	v.SetInt(5)
+	if obj.Spec.Replicas != 5 {
+		t.Fatalf("expected SetInt to mutate the original object, got %d", obj.Spec.Replicas)
+	}
+
+	if _, err := fieldByPath(obj, "spec.nope"); err == nil {
+		t.Fatalf("expected an error for an unknown field")
+	}
+}
+
+func TestTopLevelField(t *testing.T) {
+	obj := &fieldpathTestObject{Status: fieldpathTestStatus{Replicas: 2}}
+
+	v, ok := topLevelField(obj, "status")
+	if !ok {
+		t.Fatalf("expected to find the status field")
+	}
+	if v.Interface().(fieldpathTestStatus).Replicas != 2 {
+		t.Fatalf("unexpected status value: %+v", v.Interface())
+	}
+
+	if _, ok := topLevelField(obj, "nonexistent"); ok {
+		t.Fatalf("expected no match for a nonexistent field")
+	}
+}
+
+func TestRestoreField(t *testing.T) {
+	dst := &fieldpathTestObject{Status: fieldpathTestStatus{Replicas: 99}}
+	src := &fieldpathTestObject{Status: fieldpathTestStatus{Replicas: 1}}
+
+	restoreField(dst, src, "status")
+
+	if !reflect.DeepEqual(dst.Status, src.Status) {
+		t.Fatalf("expected dst.Status to be restored to src.Status, got %+v", dst.Status)
+	}
+}
@@ -3,29 +3,35 @@ package filepath
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/http"
 	"reflect"
-	"strings"
-	"sync"
-
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/henderiw/apiserver-runtime-example/pkg/metrics"
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage"
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage/disk"
+	"github.com/spf13/afero"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
 	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
 )
 
-// ErrFileNotExists means the file doesn't actually exist.
-var ErrFileNotExists = fmt.Errorf("file doesn't exist")
-
 // ErrNamespaceNotExists means the directory for the namespace doesn't actually exist.
 var ErrNamespaceNotExists = errors.New("namespace does not exist")
 
@@ -33,7 +39,10 @@ var _ rest.StandardStorage = &filepathREST{}
 var _ rest.Scoper = &filepathREST{}
 var _ rest.Storage = &filepathREST{}
 
-// NewFilepathREST instantiates a new REST storage.
+// NewFilepathREST instantiates a new REST storage backed by one JSON file
+// per object on the local filesystem, rooted at rootpath. It is a thin
+// wrapper around NewREST using the disk storage.Backend; use NewREST
+// directly to pick a different driver (BoltDB, etcd, in-memory, ...).
 func NewFilepathREST(
 	groupResource schema.GroupResource,
 	codec runtime.Codec,
@@ -42,45 +51,61 @@ func NewFilepathREST(
 	newFunc func() runtime.Object,
 	newListFunc func() runtime.Object,
 ) rest.Storage {
-	objRoot := filepath.Join(rootpath, groupResource.Group, groupResource.Resource)
-	if err := ensureDir(objRoot); err != nil {
-		panic(fmt.Sprintf("unable to write data dir: %s", err))
-	}
+	return NewREST(groupResource, codec, disk.New(rootpath), isNamespaced, newFunc, newListFunc)
+}
 
-	// file REST
-	rest := &filepathREST{
+// NewFilepathRESTWithFs is NewFilepathREST with the underlying filesystem
+// made explicit, so callers can run against an in-memory afero.Fs in tests
+// or an object-storage-backed afero.Fs (see pkg/storage/objectfs) in
+// production, instead of the real OS filesystem.
+func NewFilepathRESTWithFs(
+	groupResource schema.GroupResource,
+	codec runtime.Codec,
+	rootpath string,
+	fs afero.Fs,
+	isNamespaced bool,
+	newFunc func() runtime.Object,
+	newListFunc func() runtime.Object,
+) rest.Storage {
+	return NewREST(groupResource, codec, disk.NewWithFs(rootpath, fs), isNamespaced, newFunc, newListFunc)
+}
+
+// NewREST instantiates a new REST storage on top of the given storage.Backend,
+// letting callers pick the persistence driver (disk, boltdb, etcd, memory, ...)
+// independently of the REST/runtime.Object plumbing.
+func NewREST(
+	groupResource schema.GroupResource,
+	codec runtime.Codec,
+	backend storage.Backend,
+	isNamespaced bool,
+	newFunc func() runtime.Object,
+	newListFunc func() runtime.Object,
+) rest.Storage {
+	return &filepathREST{
 		groupResource:  groupResource,
 		TableConvertor: rest.NewDefaultTableConvertor(groupResource),
 		codec:          codec,
-		objRootPath:    objRoot,
+		backend:        backend,
 		isNamespaced:   isNamespaced,
 		newFunc:        newFunc,
 		newListFunc:    newListFunc,
-		watchers:       make(map[int]*jsonWatch, 10),
 	}
-	return rest
 }
 
+// filepathREST is a thin rest.StandardStorage adapter: it knows how to
+// encode/decode runtime.Objects and how to satisfy the REST verbs, but
+// delegates all actual persistence to a storage.Backend.
 type filepathREST struct {
 	groupResource schema.GroupResource
 	rest.TableConvertor
 	codec        runtime.Codec
-	objRootPath  string
+	backend      storage.Backend
 	isNamespaced bool
 
-	muWatchers sync.RWMutex
-	watchers   map[int]*jsonWatch
-
 	newFunc     func() runtime.Object
 	newListFunc func() runtime.Object
-}
 
-func (f *filepathREST) notifyWatchers(ev watch.Event) {
-	f.muWatchers.RLock()
-	for _, w := range f.watchers {
-		w.ch <- ev
-	}
-	f.muWatchers.RUnlock()
+	activeWatchers int64
 }
 
 func (f *filepathREST) New() runtime.Object {
@@ -97,38 +122,263 @@ func (f *filepathREST) NamespaceScoped() bool {
 	return f.isNamespaced
 }
 
+func (f *filepathREST) key(ctx context.Context, name string) (storage.Key, error) {
+	key := storage.Key{GroupResource: f.groupResource, Name: name}
+	if f.isNamespaced {
+		ns, ok := genericapirequest.NamespaceFrom(ctx)
+		if !ok {
+			return storage.Key{}, ErrNamespaceNotExists
+		}
+		key.Namespace = ns
+	}
+	return key, nil
+}
+
+func (f *filepathREST) namespace(ctx context.Context) (string, error) {
+	if !f.isNamespaced {
+		return "", nil
+	}
+	ns, ok := genericapirequest.NamespaceFrom(ctx)
+	if !ok {
+		return "", ErrNamespaceNotExists
+	}
+	return ns, nil
+}
+
+// matches reports whether obj satisfies options' label and field selectors.
+// A nil or empty selector always matches. Field selection only supports the
+// metadata.name/metadata.namespace fields every object has, the same default
+// upstream generic registries fall back to when a type registers no richer
+// field set.
+func (f *filepathREST) matches(obj runtime.Object, options *metainternalversion.ListOptions) (bool, error) {
+	if options == nil {
+		return true, nil
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+	if options.LabelSelector != nil && !options.LabelSelector.Empty() {
+		if !options.LabelSelector.Matches(labels.Set(accessor.GetLabels())) {
+			return false, nil
+		}
+	}
+	if options.FieldSelector != nil && !options.FieldSelector.Empty() {
+		objectMeta := &metav1.ObjectMeta{Name: accessor.GetName(), Namespace: accessor.GetNamespace()}
+		if !options.FieldSelector.Matches(generic.ObjectMetaFieldsSet(objectMeta, f.isNamespaced)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// listSortKey gives every object a stable total order (namespace, then name)
+// so List's continue token can unambiguously resume after it.
+func listSortKey(key storage.Key) string {
+	return key.Namespace + "/" + key.Name
+}
+
+// encodeContinue and decodeContinue turn the last key visited in a page into
+// an opaque token, matching the "clients must treat continue as opaque"
+// contract upstream kube-apiserver documents.
+func encodeContinue(lastKey string) string {
+	return base64.URLEncoding.EncodeToString([]byte(lastKey))
+}
+
+func decodeContinue(token string) (string, error) {
+	b, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid continue token: %w", err)
+	}
+	return string(b), nil
+}
+
+func (f *filepathREST) decode(vo storage.VersionedObject) (runtime.Object, error) {
+	newObj := f.newFunc()
+	decodedObj, _, err := f.codec.Decode(vo.Data, nil, newObj)
+	if err != nil {
+		return nil, err
+	}
+	accessor, err := meta.Accessor(decodedObj)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetResourceVersion(vo.ResourceVersion)
+	return decodedObj, nil
+}
+
+// bookmarkObject returns an empty object carrying only resourceVersion, to
+// pair with a watch.Bookmark event the same way upstream kube-apiserver does.
+func (f *filepathREST) bookmarkObject(resourceVersion string) runtime.Object {
+	obj := f.newFunc()
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetResourceVersion(resourceVersion)
+	}
+	return obj
+}
+
+func (f *filepathREST) encode(obj runtime.Object) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := f.codec.Encode(obj, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// statusCode maps err to the HTTP status code it would surface as, for the
+// request_duration_seconds metric's "code" label.
+func statusCode(err error) string {
+	if err == nil {
+		return strconv.Itoa(http.StatusOK)
+	}
+	if status, ok := err.(apierrors.APIStatus); ok {
+		return strconv.Itoa(int(status.Status().Code))
+	}
+	return strconv.Itoa(http.StatusInternalServerError)
+}
+
+// logFor returns a request-scoped logger carrying the groupResource and,
+// when non-empty, the object name, the same way upstream kube-apiserver
+// attributes its storage-layer logs.
+func (f *filepathREST) logFor(ctx context.Context, name string) klog.Logger {
+	log := klog.FromContext(ctx).WithValues("groupResource", f.groupResource.String())
+	if name != "" {
+		log = log.WithValues("name", name)
+	}
+	return log
+}
+
+// instrument runs fn, logging its outcome and latency and recording it
+// against apiserver_filepath_request_duration_seconds, for the verbs that
+// return a single runtime.Object (Get, List, Create, DeleteCollection).
+func (f *filepathREST) instrument(ctx context.Context, verb, name string, fn func() (runtime.Object, error)) (runtime.Object, error) {
+	start := time.Now()
+	log := f.logFor(ctx, name)
+	obj, err := fn()
+	f.record(log, verb, start, err)
+	return obj, err
+}
+
+// instrumentWrite is instrument's counterpart for Update and Delete, which
+// also return a bool (whether Update created the object, whether Delete
+// deleted it).
+func (f *filepathREST) instrumentWrite(ctx context.Context, verb, name string, fn func() (runtime.Object, bool, error)) (runtime.Object, bool, error) {
+	start := time.Now()
+	log := f.logFor(ctx, name)
+	obj, created, err := fn()
+	f.record(log, verb, start, err)
+	return obj, created, err
+}
+
+func (f *filepathREST) record(log klog.Logger, verb string, start time.Time, err error) {
+	latency := time.Since(start)
+	metrics.ObserveRequest(verb, f.groupResource.String(), statusCode(err), latency)
+	if err != nil {
+		log.Error(err, verb, "latency", latency)
+		return
+	}
+	log.V(4).Info(verb, "latency", latency)
+}
+
 func (f *filepathREST) Get(
 	ctx context.Context,
 	name string,
 	options *metav1.GetOptions,
 ) (runtime.Object, error) {
-	obj, err := read(f.codec, f.objectFileName(ctx, name), f.newFunc)
-	if err != nil {
-		if !strings.Contains(err.Error(), "no such file or directory") {
+	return f.instrument(ctx, "get", name, func() (runtime.Object, error) {
+		key, err := f.key(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		vo, err := f.backend.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, apierrors.NewNotFound(f.groupResource, name)
+			}
 			return nil, apierrors.NewBadRequest(err.Error())
 		}
-		return nil, apierrors.NewNotFound(f.groupResource, name)
-	}
-	return obj, nil
+		return f.decode(vo)
+	})
 }
 
+// List applies options' label/field selectors and, when options.Limit is
+// set, paginates the (stably sorted) result, returning an opaque
+// options.Continue token for the caller to pass back for the next page.
 func (f *filepathREST) List(
 	ctx context.Context,
 	options *metainternalversion.ListOptions,
 ) (runtime.Object, error) {
-	newListObj := f.NewList()
-	v, err := getListPrt(newListObj)
-	if err != nil {
-		return nil, err
-	}
+	return f.instrument(ctx, "list", "", func() (runtime.Object, error) {
+		newListObj := f.NewList()
+		v, err := getListPrt(newListObj)
+		if err != nil {
+			return nil, err
+		}
 
-	dirname := f.objectDirName(ctx)
-	if err := visitDir(dirname, f.newFunc, f.codec, func(path string, obj runtime.Object) {
-		appendItem(v, obj)
-	}); err != nil {
-		return nil, fmt.Errorf("failed walking filepath %v", dirname)
-	}
-	return newListObj, nil
+		ns, err := f.namespace(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := f.backend.List(ctx, f.groupResource, ns)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing %v: %w", f.groupResource, err)
+		}
+
+		type entry struct {
+			key storage.Key
+			obj runtime.Object
+		}
+		entries := make([]entry, 0, len(objects))
+		for key, vo := range objects {
+			obj, err := f.decode(vo)
+			if err != nil {
+				return nil, err
+			}
+			matches, err := f.matches(obj, options)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+			entries = append(entries, entry{key: key, obj: obj})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return listSortKey(entries[i].key) < listSortKey(entries[j].key)
+		})
+
+		start := 0
+		if options != nil && options.Continue != "" {
+			after, err := decodeContinue(options.Continue)
+			if err != nil {
+				return nil, apierrors.NewBadRequest(err.Error())
+			}
+			start = sort.Search(len(entries), func(i int) bool {
+				return listSortKey(entries[i].key) > after
+			})
+		}
+
+		end := len(entries)
+		var continueToken string
+		if options != nil && options.Limit > 0 && int64(len(entries)-start) > options.Limit {
+			end = start + int(options.Limit)
+			continueToken = encodeContinue(listSortKey(entries[end-1].key))
+		}
+
+		for _, e := range entries[start:end] {
+			appendItem(v, e.obj)
+		}
+
+		if listMeta, err := meta.ListAccessor(newListObj); err == nil {
+			listMeta.SetContinue(continueToken)
+			if continueToken != "" {
+				remaining := int64(len(entries) - end)
+				listMeta.SetRemainingItemCount(&remaining)
+			}
+		}
+
+		return newListObj, nil
+	})
 }
 
 func (f *filepathREST) Create(
@@ -137,43 +387,40 @@ func (f *filepathREST) Create(
 	createValidation rest.ValidateObjectFunc,
 	options *metav1.CreateOptions,
 ) (runtime.Object, error) {
-	if createValidation != nil {
-		if err := createValidation(ctx, obj); err != nil {
-			return nil, err
-		}
+	name := ""
+	if accessor, err := meta.Accessor(obj); err == nil {
+		name = accessor.GetName()
 	}
+	return f.instrument(ctx, "create", name, func() (runtime.Object, error) {
+		if createValidation != nil {
+			if err := createValidation(ctx, obj); err != nil {
+				return nil, err
+			}
+		}
 
-	if f.isNamespaced {
-		// ensures namespace dir
-		ns, ok := genericapirequest.NamespaceFrom(ctx)
-		if !ok {
-			return nil, ErrNamespaceNotExists
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
 		}
-		if err := ensureDir(filepath.Join(f.objRootPath, ns)); err != nil {
+		key, err := f.key(ctx, accessor.GetName())
+		if err != nil {
 			return nil, err
 		}
-	}
-
-	accessor, err := meta.Accessor(obj)
-	if err != nil {
-		return nil, err
-	}
-	filename := f.objectFileName(ctx, accessor.GetName())
-
-	if exists(filename) {
-		return nil, ErrFileNotExists
-	}
 
-	if err := write(f.codec, filename, obj); err != nil {
-		return nil, err
-	}
-
-	f.notifyWatchers(watch.Event{
-		Type:   watch.Added,
-		Object: obj,
+		data, err := f.encode(obj)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := f.backend.Create(ctx, key, data)
+		if err != nil {
+			if errors.Is(err, storage.ErrAlreadyExists) {
+				return nil, apierrors.NewAlreadyExists(f.groupResource, accessor.GetName())
+			}
+			return nil, err
+		}
+		accessor.SetResourceVersion(rv)
+		return obj, nil
 	})
-
-	return obj, nil
 }
 
 func (f *filepathREST) Update(
@@ -185,62 +432,79 @@ func (f *filepathREST) Update(
 	forceAllowCreate bool,
 	options *metav1.UpdateOptions,
 ) (runtime.Object, bool, error) {
-	isCreate := false
-	oldObj, err := f.Get(ctx, name, nil)
-	if err != nil {
-		if !forceAllowCreate {
-			return nil, false, err
+	return f.instrumentWrite(ctx, "update", name, func() (runtime.Object, bool, error) {
+		isCreate := false
+		oldObj, err := f.Get(ctx, name, nil)
+		if err != nil {
+			if !forceAllowCreate {
+				return nil, false, err
+			}
+			isCreate = true
 		}
-		isCreate = true
-	}
 
-	// TODO: should not be necessary, verify Get works before creating filepath
-	if f.isNamespaced {
-		// ensures namespace dir
-		ns, ok := genericapirequest.NamespaceFrom(ctx)
-		if !ok {
-			return nil, false, ErrNamespaceNotExists
+		updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
+		if err != nil {
+			return nil, false, err
 		}
-		if err := ensureDir(filepath.Join(f.objRootPath, ns)); err != nil {
+		key, err := f.key(ctx, name)
+		if err != nil {
 			return nil, false, err
 		}
-	}
 
-	updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
-	if err != nil {
-		return nil, false, err
-	}
-	filename := f.objectFileName(ctx, name)
+		updatedAccessor, err := meta.Accessor(updatedObj)
+		if err != nil {
+			return nil, false, err
+		}
 
-	if isCreate {
-		if createValidation != nil {
-			if err := createValidation(ctx, updatedObj); err != nil {
+		if !isCreate {
+			// .status is only mutable through the /status subresource; pin it
+			// back to its previous value here the same way upstream
+			// kube-apiserver's main-resource update strategy does.
+			restoreField(updatedObj, oldObj, "status")
+		}
+
+		if isCreate {
+			if createValidation != nil {
+				if err := createValidation(ctx, updatedObj); err != nil {
+					return nil, false, err
+				}
+			}
+			data, err := f.encode(updatedObj)
+			if err != nil {
 				return nil, false, err
 			}
+			rv, err := f.backend.Create(ctx, key, data)
+			if err != nil {
+				return nil, false, err
+			}
+			updatedAccessor.SetResourceVersion(rv)
+			return updatedObj, true, nil
 		}
-		if err := write(f.codec, filename, updatedObj); err != nil {
+
+		if updateValidation != nil {
+			if err := updateValidation(ctx, updatedObj, oldObj); err != nil {
+				return nil, false, err
+			}
+		}
+		data, err := f.encode(updatedObj)
+		if err != nil {
 			return nil, false, err
 		}
-		f.notifyWatchers(watch.Event{
-			Type:   watch.Added,
-			Object: updatedObj,
-		})
-		return updatedObj, true, nil
-	}
-
-	if updateValidation != nil {
-		if err := updateValidation(ctx, updatedObj, oldObj); err != nil {
+		// An empty resourceVersion means "last write wins"; a non-empty one must
+		// match what's currently stored or the update is rejected as a conflict.
+		rv, err := f.backend.Update(ctx, key, data, updatedAccessor.GetResourceVersion())
+		if err != nil {
+			if errors.Is(err, storage.ErrConflict) {
+				return nil, false, apierrors.NewConflict(f.groupResource, name, err)
+			}
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, false, apierrors.NewNotFound(f.groupResource, name)
+			}
 			return nil, false, err
 		}
-	}
-	if err := write(f.codec, filename, updatedObj); err != nil {
-		return nil, false, err
-	}
-	f.notifyWatchers(watch.Event{
-		Type:   watch.Modified,
-		Object: updatedObj,
+		updatedAccessor.SetResourceVersion(rv)
+		return updatedObj, false, nil
 	})
-	return updatedObj, false, nil
 }
 
 func (f *filepathREST) Delete(
@@ -248,29 +512,36 @@ func (f *filepathREST) Delete(
 	name string,
 	deleteValidation rest.ValidateObjectFunc,
 	options *metav1.DeleteOptions) (runtime.Object, bool, error) {
-	filename := f.objectFileName(ctx, name)
-	if !exists(filename) {
-		return nil, false, ErrFileNotExists
-	}
-
-	oldObj, err := f.Get(ctx, name, nil)
-	if err != nil {
-		return nil, false, err
-	}
-	if deleteValidation != nil {
-		if err := deleteValidation(ctx, oldObj); err != nil {
+	return f.instrumentWrite(ctx, "delete", name, func() (runtime.Object, bool, error) {
+		oldObj, err := f.Get(ctx, name, nil)
+		if err != nil {
 			return nil, false, err
 		}
-	}
+		if deleteValidation != nil {
+			if err := deleteValidation(ctx, oldObj); err != nil {
+				return nil, false, err
+			}
+		}
 
-	if err := os.Remove(filename); err != nil {
-		return nil, false, err
-	}
-	f.notifyWatchers(watch.Event{
-		Type:   watch.Deleted,
-		Object: oldObj,
+		key, err := f.key(ctx, name)
+		if err != nil {
+			return nil, false, err
+		}
+		var expectedResourceVersion string
+		if options != nil && options.Preconditions != nil && options.Preconditions.ResourceVersion != nil {
+			expectedResourceVersion = *options.Preconditions.ResourceVersion
+		}
+		if _, err := f.backend.Delete(ctx, key, expectedResourceVersion); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, false, apierrors.NewNotFound(f.groupResource, name)
+			}
+			if errors.Is(err, storage.ErrConflict) {
+				return nil, false, apierrors.NewConflict(f.groupResource, name, err)
+			}
+			return nil, false, err
+		}
+		return oldObj, true, nil
 	})
-	return oldObj, true, nil
 }
 
 func (f *filepathREST) DeleteCollection(
@@ -279,89 +550,36 @@ func (f *filepathREST) DeleteCollection(
 	options *metav1.DeleteOptions,
 	listOptions *metainternalversion.ListOptions,
 ) (runtime.Object, error) {
-	newListObj := f.NewList()
-	v, err := getListPrt(newListObj)
-	if err != nil {
-		return nil, err
-	}
-	dirname := f.objectDirName(ctx)
-	if err := visitDir(dirname, f.newFunc, f.codec, func(path string, obj runtime.Object) {
-		_ = os.Remove(path)
-		appendItem(v, obj)
-	}); err != nil {
-		return nil, fmt.Errorf("failed walking filepath %v", dirname)
-	}
-	return newListObj, nil
-}
-
-func (f *filepathREST) objectFileName(ctx context.Context, name string) string {
-	if f.isNamespaced {
-		// FIXME: return error if namespace is not found
-		ns, _ := genericapirequest.NamespaceFrom(ctx)
-		return filepath.Join(f.objRootPath, ns, name+".json")
-	}
-	return filepath.Join(f.objRootPath, name+".json")
-}
-
-func (f *filepathREST) objectDirName(ctx context.Context) string {
-	if f.isNamespaced {
-		// FIXME: return error if namespace is not found
-		ns, _ := genericapirequest.NamespaceFrom(ctx)
-		return filepath.Join(f.objRootPath, ns)
-	}
-	return f.objRootPath
-}
-
-func write(encoder runtime.Encoder, filepath string, obj runtime.Object) error {
-	buf := new(bytes.Buffer)
-	if err := encoder.Encode(obj, buf); err != nil {
-		return err
-	}
-	return os.WriteFile(filepath, buf.Bytes(), 0600)
-}
-
-func read(decoder runtime.Decoder, path string, newFunc func() runtime.Object) (runtime.Object, error) {
-	content, err := os.ReadFile(filepath.Clean(path))
-	if err != nil {
-		return nil, err
-	}
-	newObj := newFunc()
-	decodedObj, _, err := decoder.Decode(content, nil, newObj)
-	if err != nil {
-		return nil, err
-	}
-	return decodedObj, nil
-}
-
-func exists(filepath string) bool {
-	_, err := os.Stat(filepath)
-	return err == nil
-}
-
-func ensureDir(dirname string) error {
-	if !exists(dirname) {
-		return os.MkdirAll(dirname, 0700)
-	}
-	return nil
-}
-
-func visitDir(dirname string, newFunc func() runtime.Object, codec runtime.Decoder, visitFunc func(string, runtime.Object)) error {
-	return filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
+	return f.instrument(ctx, "deletecollection", "", func() (runtime.Object, error) {
+		newListObj := f.NewList()
+		v, err := getListPrt(newListObj)
 		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
+			return nil, err
 		}
-		if !strings.HasSuffix(info.Name(), ".json") {
-			return nil
+		ns, err := f.namespace(ctx)
+		if err != nil {
+			return nil, err
 		}
-		newObj, err := read(codec, path, newFunc)
+		objects, err := f.backend.List(ctx, f.groupResource, ns)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed listing %v: %w", f.groupResource, err)
+		}
+		for key, vo := range objects {
+			obj, err := f.decode(vo)
+			if err != nil {
+				return nil, err
+			}
+			if deleteValidation != nil {
+				if err := deleteValidation(ctx, obj); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := f.backend.Delete(ctx, key, ""); err != nil {
+				return nil, err
+			}
+			appendItem(v, obj)
 		}
-		visitFunc(path, newObj)
-		return nil
+		return newListObj, nil
 	})
 }
 
@@ -381,46 +599,139 @@ func getListPrt(listObj runtime.Object) (reflect.Value, error) {
 	return v, nil
 }
 
+// Watch honors options.ResourceVersion the way upstream kube-apiserver does:
+//   - "" watches from now on, after sending an Added event for every object
+//     that currently exists (the historical behavior of this example).
+//   - "0" does the same initial dump, but also replays every buffered event
+//     still in the backend's watch cache, so a client that just listed at
+//     RV=0 doesn't miss anything that happened concurrently.
+//   - an exact RV skips the initial dump and replays only events newer than
+//     it, returning a 410 Gone (apierrors.NewResourceExpired) if the backend
+//     can no longer satisfy it, so the caller relists and resumes.
 func (f *filepathREST) Watch(ctx context.Context, options *metainternalversion.ListOptions) (watch.Interface, error) {
-	jw := &jsonWatch{
-		id: len(f.watchers),
-		f:  f,
-		ch: make(chan watch.Event, 10),
-	}
-	// On initial watch, send all the existing objects
-	list, err := f.List(ctx, options)
+	start := time.Now()
+	log := f.logFor(ctx, "")
+
+	ns, err := f.namespace(ctx)
 	if err != nil {
+		f.record(log, "watch", start, err)
 		return nil, err
 	}
 
-	danger := reflect.ValueOf(list).Elem()
-	items := danger.FieldByName("Items")
+	var resourceVersion string
+	var dumpList bool
+	if options != nil {
+		resourceVersion = options.ResourceVersion
+	}
+	switch resourceVersion {
+	case "", "0":
+		dumpList = true
+	}
+
+	backendWatch, err := f.backend.Watch(ctx, f.groupResource, ns, resourceVersion)
+	if err != nil {
+		if errors.Is(err, storage.ErrResourceVersionTooOld) {
+			err = apierrors.NewResourceExpired(fmt.Sprintf("resourceVersion %q is no longer available; relist", resourceVersion))
+		}
+		f.record(log, "watch", start, err)
+		return nil, err
+	}
 
-	for i := 0; i < items.Len(); i++ {
-		obj := items.Index(i).Addr().Interface().(runtime.Object)
-		jw.ch <- watch.Event{
-			Type:   watch.Added,
-			Object: obj,
+	jw := &jsonWatch{
+		backendWatch: backendWatch,
+		f:            f,
+		options:      options,
+		ch:           make(chan watch.Event, 10),
+	}
+
+	if dumpList {
+		// options.Limit/Continue don't apply to the initial watch dump:
+		// watch is a full resync, not a page.
+		listOptions := options
+		if listOptions != nil && (listOptions.Limit > 0 || listOptions.Continue != "") {
+			clone := *listOptions
+			clone.Limit = 0
+			clone.Continue = ""
+			listOptions = &clone
+		}
+		list, err := f.List(ctx, listOptions)
+		if err != nil {
+			backendWatch.Stop()
+			f.record(log, "watch", start, err)
+			return nil, err
+		}
+		danger := reflect.ValueOf(list).Elem()
+		items := danger.FieldByName("Items")
+		for i := 0; i < items.Len(); i++ {
+			obj := items.Index(i).Addr().Interface().(runtime.Object)
+			jw.ch <- watch.Event{
+				Type:   watch.Added,
+				Object: obj,
+			}
 		}
 	}
 
-	f.muWatchers.Lock()
-	f.watchers[jw.id] = jw
-	f.muWatchers.Unlock()
+	n := atomic.AddInt64(&f.activeWatchers, 1)
+	metrics.SetActiveWatchers(f.groupResource.String(), int(n))
+	go jw.relay()
 
+	f.record(log, "watch", start, nil)
 	return jw, nil
 }
 
 type jsonWatch struct {
-	f  *filepathREST
-	id int
-	ch chan watch.Event
+	f            *filepathREST
+	backendWatch storage.Watcher
+	options      *metainternalversion.ListOptions
+	ch           chan watch.Event
+}
+
+// relay decodes backend events onto w.ch, dropping (rather than blocking on)
+// any event a slow subscriber hasn't drained room for, since one stuck
+// watcher must never stall the backend's delivery to everybody else.
+func (w *jsonWatch) relay() {
+	resource := w.f.groupResource.String()
+	defer func() {
+		n := atomic.AddInt64(&w.f.activeWatchers, -1)
+		metrics.SetActiveWatchers(resource, int(n))
+	}()
+
+	for ev := range w.backendWatch.ResultChan() {
+		var event watch.Event
+		switch ev.Type {
+		case storage.Bookmark:
+			event = watch.Event{Type: watch.Bookmark, Object: w.f.bookmarkObject(ev.ResourceVersion)}
+		default:
+			obj, err := w.f.decode(storage.VersionedObject{Data: ev.Data, ResourceVersion: ev.ResourceVersion})
+			if err != nil {
+				continue
+			}
+			if matches, err := w.f.matches(obj, w.options); err != nil || !matches {
+				continue
+			}
+			var eventType watch.EventType
+			switch ev.Type {
+			case storage.Added:
+				eventType = watch.Added
+			case storage.Modified:
+				eventType = watch.Modified
+			case storage.Deleted:
+				eventType = watch.Deleted
+			}
+			event = watch.Event{Type: eventType, Object: obj}
+		}
+
+		select {
+		case w.ch <- event:
+			metrics.IncWatchEvent(resource, "emitted")
+		default:
+			metrics.IncWatchEvent(resource, "dropped")
+		}
+	}
 }
 
 func (w *jsonWatch) Stop() {
-	w.f.muWatchers.Lock()
-	delete(w.f.watchers, w.id)
-	w.f.muWatchers.Unlock()
+	w.backendWatch.Stop()
 }
 
 func (w *jsonWatch) ResultChan() <-chan watch.Event {
@@ -0,0 +1,83 @@
+package filepath
+
+import (
+	"testing"
+
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestEncodeDecodeContinue(t *testing.T) {
+	token := encodeContinue("ns/name")
+	got, err := decodeContinue(token)
+	if err != nil {
+		t.Fatalf("decodeContinue: %v", err)
+	}
+	if got != "ns/name" {
+		t.Fatalf("expected %q, got %q", "ns/name", got)
+	}
+
+	if _, err := decodeContinue("not-valid-base64!!"); err == nil {
+		t.Fatalf("expected an error decoding an invalid continue token")
+	}
+}
+
+func TestListSortKeyOrdering(t *testing.T) {
+	a := listSortKey(storage.Key{Name: "a"})
+	b := listSortKey(storage.Key{Name: "b"})
+	if !(a < b) {
+		t.Fatalf("expected %q < %q", a, b)
+	}
+
+	nsA := listSortKey(storage.Key{Namespace: "ns1", Name: "z"})
+	nsB := listSortKey(storage.Key{Namespace: "ns2", Name: "a"})
+	if !(nsA < nsB) {
+		t.Fatalf("expected namespace to sort before name: %q should be < %q", nsA, nsB)
+	}
+}
+
+func TestFilepathRESTMatches(t *testing.T) {
+	f := &filepathREST{isNamespaced: true}
+	obj := &fieldpathTestObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "widget-1",
+			Namespace: "ns",
+			Labels:    map[string]string{"tier": "frontend"},
+		},
+	}
+
+	ok, err := f.matches(obj, nil)
+	if err != nil || !ok {
+		t.Fatalf("expected nil options to always match, got ok=%v err=%v", ok, err)
+	}
+
+	sel, err := labels.Parse("tier=frontend")
+	if err != nil {
+		t.Fatalf("labels.Parse: %v", err)
+	}
+	ok, err = f.matches(obj, &metainternalversion.ListOptions{LabelSelector: sel})
+	if err != nil || !ok {
+		t.Fatalf("expected label selector tier=frontend to match, got ok=%v err=%v", ok, err)
+	}
+
+	sel, err = labels.Parse("tier=backend")
+	if err != nil {
+		t.Fatalf("labels.Parse: %v", err)
+	}
+	ok, err = f.matches(obj, &metainternalversion.ListOptions{LabelSelector: sel})
+	if err != nil || ok {
+		t.Fatalf("expected label selector tier=backend not to match, got ok=%v err=%v", ok, err)
+	}
+
+	fsel, err := fields.ParseSelector("metadata.name=widget-1")
+	if err != nil {
+		t.Fatalf("fields.ParseSelector: %v", err)
+	}
+	ok, err = f.matches(obj, &metainternalversion.ListOptions{FieldSelector: fsel})
+	if err != nil || !ok {
+		t.Fatalf("expected field selector metadata.name=widget-1 to match, got ok=%v err=%v", ok, err)
+	}
+}
@@ -0,0 +1,147 @@
+package filepath
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// scaleREST implements the /scale subresource, projecting an arbitrary
+// resource's replica count and label selector onto autoscaling/v1.Scale,
+// the contract `kubectl scale` and the HorizontalPodAutoscaler expect from
+// any scalable resource.
+type scaleREST struct {
+	parent             *filepathREST
+	specReplicasPath   string
+	statusReplicasPath string
+	labelSelectorPath  string
+}
+
+var _ rest.Storage = &scaleREST{}
+var _ rest.Getter = &scaleREST{}
+var _ rest.Updater = &scaleREST{}
+var _ rest.Scoper = &scaleREST{}
+
+// NewScaleREST returns a /scale subresource storage for parent. The three
+// paths are dotted, case-insensitive field paths into parent's object type
+// (e.g. "spec.replicas", "status.replicas", "status.selector");
+// labelSelectorPath may be empty if the resource has no selector to report.
+func NewScaleREST(parent *filepathREST, specReplicasPath, statusReplicasPath, labelSelectorPath string) rest.Storage {
+	return &scaleREST{
+		parent:             parent,
+		specReplicasPath:   specReplicasPath,
+		statusReplicasPath: statusReplicasPath,
+		labelSelectorPath:  labelSelectorPath,
+	}
+}
+
+func (r *scaleREST) New() runtime.Object {
+	return &autoscalingv1.Scale{}
+}
+
+func (r *scaleREST) Destroy() {}
+
+func (r *scaleREST) NamespaceScoped() bool {
+	return r.parent.NamespaceScoped()
+}
+
+func (r *scaleREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	obj, err := r.parent.Get(ctx, name, options)
+	if err != nil {
+		return nil, err
+	}
+	return r.toScale(obj)
+}
+
+func (r *scaleREST) Update(
+	ctx context.Context,
+	name string,
+	objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc,
+	updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool,
+	options *metav1.UpdateOptions,
+) (runtime.Object, bool, error) {
+	oldObj, err := r.parent.Get(ctx, name, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	oldScale, err := r.toScale(oldObj)
+	if err != nil {
+		return nil, false, err
+	}
+	updatedScaleObj, err := objInfo.UpdatedObject(ctx, oldScale)
+	if err != nil {
+		return nil, false, err
+	}
+	updatedScale, ok := updatedScaleObj.(*autoscalingv1.Scale)
+	if !ok {
+		return nil, false, fmt.Errorf("expected *autoscaling/v1.Scale, got %T", updatedScaleObj)
+	}
+
+	specReplicas, err := fieldByPath(oldObj, r.specReplicasPath)
+	if err != nil {
+		return nil, false, err
+	}
+	if !specReplicas.CanSet() || specReplicas.Kind() != reflect.Int32 {
+		return nil, false, fmt.Errorf("%s: not a settable int32 field", r.specReplicasPath)
+	}
+	specReplicas.SetInt(int64(updatedScale.Spec.Replicas))
+
+	updatedParent, _, err := r.parent.Update(
+		ctx, name,
+		rest.DefaultUpdatedObjectInfo(oldObj),
+		createValidation, updateValidation, forceAllowCreate, options,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	newScale, err := r.toScale(updatedParent)
+	if err != nil {
+		return nil, false, err
+	}
+	return newScale, false, nil
+}
+
+func (r *scaleREST) toScale(obj runtime.Object) (*autoscalingv1.Scale, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	specReplicas, err := fieldByPath(obj, r.specReplicasPath)
+	if err != nil {
+		return nil, err
+	}
+	statusReplicas, err := fieldByPath(obj, r.statusReplicasPath)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              accessor.GetName(),
+			Namespace:         accessor.GetNamespace(),
+			UID:               accessor.GetUID(),
+			ResourceVersion:   accessor.GetResourceVersion(),
+			CreationTimestamp: accessor.GetCreationTimestamp(),
+		},
+		Spec: autoscalingv1.ScaleSpec{
+			Replicas: int32(specReplicas.Int()),
+		},
+		Status: autoscalingv1.ScaleStatus{
+			Replicas: int32(statusReplicas.Int()),
+		},
+	}
+	if r.labelSelectorPath != "" {
+		if sel, err := fieldByPath(obj, r.labelSelectorPath); err == nil && sel.Kind() == reflect.String {
+			scale.Status.Selector = sel.String()
+		}
+	}
+	return scale, nil
+}
@@ -0,0 +1,129 @@
+package filepath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// statusREST implements the /status subresource: the same object as its
+// parent, but Update only honors changes to .status, rejecting anything else
+// with a validation error rather than silently dropping it.
+type statusREST struct {
+	parent *filepathREST
+}
+
+var _ rest.Storage = &statusREST{}
+var _ rest.Getter = &statusREST{}
+var _ rest.Updater = &statusREST{}
+var _ rest.Scoper = &statusREST{}
+
+// NewStatusREST returns a /status subresource storage for parent.
+func NewStatusREST(parent *filepathREST) rest.Storage {
+	return &statusREST{parent: parent}
+}
+
+func (r *statusREST) New() runtime.Object {
+	return r.parent.New()
+}
+
+func (r *statusREST) Destroy() {}
+
+func (r *statusREST) NamespaceScoped() bool {
+	return r.parent.NamespaceScoped()
+}
+
+func (r *statusREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	return r.parent.Get(ctx, name, options)
+}
+
+// Update re-implements the parent's plain Update, rather than delegating to
+// it, because the parent strips .status changes back out (so only this
+// subresource can make them) while this subresource must do the opposite:
+// accept .status changes and reject everything else.
+func (r *statusREST) Update(
+	ctx context.Context,
+	name string,
+	objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc,
+	updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool,
+	options *metav1.UpdateOptions,
+) (runtime.Object, bool, error) {
+	f := r.parent
+
+	oldObj, err := f.Get(ctx, name, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := requireStatusOnlyChange(oldObj, updatedObj); err != nil {
+		return nil, false, err
+	}
+	if updateValidation != nil {
+		if err := updateValidation(ctx, updatedObj, oldObj); err != nil {
+			return nil, false, err
+		}
+	}
+
+	key, err := f.key(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	updatedAccessor, err := meta.Accessor(updatedObj)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := f.encode(updatedObj)
+	if err != nil {
+		return nil, false, err
+	}
+	rv, err := f.backend.Update(ctx, key, data, updatedAccessor.GetResourceVersion())
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			return nil, false, apierrors.NewConflict(f.groupResource, name, err)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, false, apierrors.NewNotFound(f.groupResource, name)
+		}
+		return nil, false, err
+	}
+	updatedAccessor.SetResourceVersion(rv)
+	return updatedObj, false, nil
+}
+
+// requireStatusOnlyChange compares oldObj and newObj with .status zeroed out
+// on both sides, failing the update if anything else differs. Types with no
+// .status field have nothing for this subresource to split off, so they
+// always pass.
+func requireStatusOnlyChange(oldObj, newObj runtime.Object) error {
+	oldCopy := oldObj.DeepCopyObject()
+	newCopy := newObj.DeepCopyObject()
+
+	oldStatus, ok := topLevelField(oldCopy, "status")
+	if !ok {
+		return nil
+	}
+	newStatus, ok := topLevelField(newCopy, "status")
+	if !ok {
+		return nil
+	}
+	oldStatus.Set(reflect.Zero(oldStatus.Type()))
+	newStatus.Set(reflect.Zero(newStatus.Type()))
+
+	if !reflect.DeepEqual(oldCopy, newCopy) {
+		return apierrors.NewBadRequest(fmt.Sprintf("%T: only status may be changed via the status subresource", newObj))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegisterMetricsAndRecord(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	RegisterMetrics(reg)
+
+	ObserveRequest("get", "widgets.example.com", "200", 50*time.Millisecond)
+	if got := testutil.CollectAndCount(reg, "apiserver_filepath_request_duration_seconds"); got != 1 {
+		t.Fatalf("expected 1 request_duration sample series, got %d", got)
+	}
+
+	IncWatchEvent("widgets.example.com", "emitted")
+	IncWatchEvent("widgets.example.com", "dropped")
+	if got := testutil.ToFloat64(watchEventsTotal.WithLabelValues("widgets.example.com", "emitted")); got != 1 {
+		t.Fatalf("expected 1 emitted watch event, got %v", got)
+	}
+	if got := testutil.ToFloat64(watchEventsTotal.WithLabelValues("widgets.example.com", "dropped")); got != 1 {
+		t.Fatalf("expected 1 dropped watch event, got %v", got)
+	}
+
+	SetActiveWatchers("widgets.example.com", 3)
+	if got := testutil.ToFloat64(activeWatchers.WithLabelValues("widgets.example.com")); got != 3 {
+		t.Fatalf("expected 3 active watchers, got %v", got)
+	}
+}
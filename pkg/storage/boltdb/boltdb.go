@@ -0,0 +1,264 @@
+// Package boltdb implements a storage.Backend backed by a single embedded
+// BoltDB file, giving callers transactional, atomic writes without an
+// external dependency.
+package boltdb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage"
+	bolt "go.etcd.io/bbolt"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// BookmarkInterval is how often a watch.Bookmark-carrying Event is
+// broadcast to subscribers, matching upstream kube-apiserver's default.
+const BookmarkInterval = 60 * time.Second
+
+// New opens (creating if necessary) a BoltDB file at path and returns a
+// storage.Backend over it. The returned backend owns the underlying file
+// handle; call Close when done.
+func New(path string) (*Backend, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open boltdb file %s: %w", path, err)
+	}
+	b := &Backend{
+		db:    db,
+		cache: storage.NewWatchCache(storage.DefaultWatchCacheCapacity, BookmarkInterval),
+	}
+	if err := b.seedRevision(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to seed resourceVersion from %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Backend is a storage.Backend backed by a BoltDB file. One bucket is used
+// per GroupResource, named "<group>/<resource>", holding the encoded object
+// bytes; a sibling "<group>/<resource>#meta" bucket holds each key's
+// resourceVersion.
+type Backend struct {
+	db  *bolt.DB
+	rev uint64
+
+	cache *storage.WatchCache
+}
+
+var _ storage.Backend = &Backend{}
+
+// Close releases the underlying BoltDB file handle and stops the watch
+// cache's bookmark goroutine.
+func (b *Backend) Close() error {
+	b.cache.Close()
+	return b.db.Close()
+}
+
+func (b *Backend) nextResourceVersion() string {
+	return strconv.FormatUint(atomic.AddUint64(&b.rev, 1), 10)
+}
+
+// seedRevision scans every "#meta" bucket already in the bolt file for the
+// highest resourceVersion previously assigned, so a restarted process keeps
+// handing out strictly increasing resourceVersions instead of starting back
+// over at 1 -- the whole point of BoltDB over the in-memory backend is that
+// the data (and therefore its resourceVersions) survives a restart.
+func (b *Backend) seedRevision() error {
+	var maxRev uint64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			if !strings.HasSuffix(string(name), "#meta") {
+				return nil
+			}
+			return bucket.ForEach(func(_, v []byte) error {
+				if rv, err := strconv.ParseUint(string(v), 10, 64); err == nil && rv > maxRev {
+					maxRev = rv
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	b.rev = maxRev
+	return nil
+}
+
+func bucketName(gr schema.GroupResource) []byte {
+	return []byte(gr.Group + "/" + gr.Resource)
+}
+
+func metaBucketName(gr schema.GroupResource) []byte {
+	return []byte(gr.Group + "/" + gr.Resource + "#meta")
+}
+
+func keyName(key storage.Key) []byte {
+	if key.Namespace != "" {
+		return []byte(key.Namespace + "/" + key.Name)
+	}
+	return []byte(key.Name)
+}
+
+func (b *Backend) Get(_ context.Context, key storage.Key) (storage.VersionedObject, error) {
+	var obj storage.VersionedObject
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(key.GroupResource))
+		if bucket == nil {
+			return storage.ErrNotFound
+		}
+		v := bucket.Get(keyName(key))
+		if v == nil {
+			return storage.ErrNotFound
+		}
+		obj.Data = append([]byte(nil), v...)
+		if meta := tx.Bucket(metaBucketName(key.GroupResource)); meta != nil {
+			obj.ResourceVersion = string(meta.Get(keyName(key)))
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.VersionedObject{}, err
+	}
+	return obj, nil
+}
+
+func (b *Backend) List(_ context.Context, gr schema.GroupResource, namespace string) (map[storage.Key]storage.VersionedObject, error) {
+	out := make(map[storage.Key]storage.VersionedObject)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(gr))
+		if bucket == nil {
+			return nil
+		}
+		meta := tx.Bucket(metaBucketName(gr))
+		return bucket.ForEach(func(k, v []byte) error {
+			key := parseKey(gr, k)
+			if namespace != "" && key.Namespace != namespace {
+				return nil
+			}
+			obj := storage.VersionedObject{Data: append([]byte(nil), v...)}
+			if meta != nil {
+				obj.ResourceVersion = string(meta.Get(k))
+			}
+			out[key] = obj
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func parseKey(gr schema.GroupResource, raw []byte) storage.Key {
+	s := string(raw)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '/' {
+			return storage.Key{GroupResource: gr, Namespace: s[:i], Name: s[i+1:]}
+		}
+	}
+	return storage.Key{GroupResource: gr, Name: s}
+}
+
+func (b *Backend) Create(_ context.Context, key storage.Key, data []byte) (string, error) {
+	rv := b.nextResourceVersion()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketName(key.GroupResource))
+		if err != nil {
+			return err
+		}
+		if bucket.Get(keyName(key)) != nil {
+			return storage.ErrAlreadyExists
+		}
+		if err := bucket.Put(keyName(key), data); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucketName(key.GroupResource))
+		if err != nil {
+			return err
+		}
+		return meta.Put(keyName(key), []byte(rv))
+	})
+	if err != nil {
+		return "", err
+	}
+	b.cache.Push(storage.Event{Type: storage.Added, Key: key, Data: data, ResourceVersion: rv})
+	return rv, nil
+}
+
+func (b *Backend) Update(_ context.Context, key storage.Key, data []byte, expectedResourceVersion string) (string, error) {
+	rv := b.nextResourceVersion()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketName(key.GroupResource))
+		if err != nil {
+			return err
+		}
+		if bucket.Get(keyName(key)) == nil {
+			return storage.ErrNotFound
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucketName(key.GroupResource))
+		if err != nil {
+			return err
+		}
+		if expectedResourceVersion != "" && string(meta.Get(keyName(key))) != expectedResourceVersion {
+			return storage.ErrConflict
+		}
+		if err := bucket.Put(keyName(key), data); err != nil {
+			return err
+		}
+		return meta.Put(keyName(key), []byte(rv))
+	})
+	if err != nil {
+		return "", err
+	}
+	b.cache.Push(storage.Event{Type: storage.Modified, Key: key, Data: data, ResourceVersion: rv})
+	return rv, nil
+}
+
+func (b *Backend) Delete(_ context.Context, key storage.Key, expectedResourceVersion string) (storage.VersionedObject, error) {
+	var obj storage.VersionedObject
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(key.GroupResource))
+		if bucket == nil {
+			return storage.ErrNotFound
+		}
+		v := bucket.Get(keyName(key))
+		if v == nil {
+			return storage.ErrNotFound
+		}
+		meta := tx.Bucket(metaBucketName(key.GroupResource))
+		var rv string
+		if meta != nil {
+			rv = string(meta.Get(keyName(key)))
+		}
+		if expectedResourceVersion != "" && rv != expectedResourceVersion {
+			return storage.ErrConflict
+		}
+		obj = storage.VersionedObject{Data: append([]byte(nil), v...), ResourceVersion: rv}
+		if err := bucket.Delete(keyName(key)); err != nil {
+			return err
+		}
+		if meta != nil {
+			return meta.Delete(keyName(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.VersionedObject{}, err
+	}
+	b.cache.Push(storage.Event{Type: storage.Deleted, Key: key, Data: obj.Data, ResourceVersion: obj.ResourceVersion})
+	return obj, nil
+}
+
+// Watch delivers in-process notifications only: BoltDB has no native change
+// feed, so subscribers only see mutations made through this same Backend
+// instance (which is the common case for an embedded, single-process
+// deployment).
+func (b *Backend) Watch(_ context.Context, gr schema.GroupResource, namespace string, sinceResourceVersion string) (storage.Watcher, error) {
+	return b.cache.Watch(gr, namespace, sinceResourceVersion)
+}
@@ -0,0 +1,88 @@
+package boltdb
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var testGR = schema.GroupResource{Group: "example.com", Resource: "widgets"}
+
+func TestBackendUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	b, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer b.Close()
+
+	key := storage.Key{GroupResource: testGR, Name: "a"}
+	rv, err := b.Create(ctx, key, []byte("v1"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := b.Update(ctx, key, []byte("v2"), "not-"+rv); err != storage.ErrConflict {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if _, err := b.Update(ctx, key, []byte("v2"), rv); err != nil {
+		t.Fatalf("Update with correct resourceVersion: %v", err)
+	}
+}
+
+// TestBackendReseedsRevisionAcrossRestart guards against resourceVersions
+// resetting to 1 after a process restart, the exact scenario BoltDB is meant
+// to survive.
+func TestBackendReseedsRevisionAcrossRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	b, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	key := storage.Key{GroupResource: testGR, Name: "a"}
+	if _, err := b.Create(ctx, key, []byte("v1")); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := b.Update(ctx, key, []byte("v"), ""); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	last, err := b.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := New(path)
+	if err != nil {
+		t.Fatalf("New (reopen): %v", err)
+	}
+	defer reopened.Close()
+
+	newKey := storage.Key{GroupResource: testGR, Name: "b"}
+	rv, err := reopened.Create(ctx, newKey, []byte("v1"))
+	if err != nil {
+		t.Fatalf("Create after reopen: %v", err)
+	}
+	newRV, err := strconv.ParseUint(rv, 10, 64)
+	if err != nil {
+		t.Fatalf("parse new resourceVersion %q: %v", rv, err)
+	}
+	lastRV, err := strconv.ParseUint(last.ResourceVersion, 10, 64)
+	if err != nil {
+		t.Fatalf("parse pre-restart resourceVersion %q: %v", last.ResourceVersion, err)
+	}
+	if newRV <= lastRV {
+		t.Fatalf("expected new resourceVersion %d to be greater than pre-restart %d", newRV, lastRV)
+	}
+}
@@ -0,0 +1,122 @@
+// Package memory implements an in-memory storage.Backend, primarily useful
+// for unit tests and for examples that don't need data to survive a
+// restart.
+package memory
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// BookmarkInterval is how often a watch.Bookmark-carrying Event is
+// broadcast to subscribers, matching upstream kube-apiserver's default.
+const BookmarkInterval = 60 * time.Second
+
+// New returns an empty in-memory storage.Backend.
+func New() storage.Backend {
+	return &backend{
+		objects: make(map[storage.Key]storage.VersionedObject),
+		cache:   storage.NewWatchCache(storage.DefaultWatchCacheCapacity, BookmarkInterval),
+	}
+}
+
+type backend struct {
+	mu      sync.RWMutex
+	objects map[storage.Key]storage.VersionedObject
+	rev     uint64
+
+	cache *storage.WatchCache
+}
+
+var _ storage.Backend = &backend{}
+
+func (b *backend) nextResourceVersion() string {
+	return strconv.FormatUint(atomic.AddUint64(&b.rev, 1), 10)
+}
+
+func (b *backend) Get(_ context.Context, key storage.Key) (storage.VersionedObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	obj, ok := b.objects[key]
+	if !ok {
+		return storage.VersionedObject{}, storage.ErrNotFound
+	}
+	return obj, nil
+}
+
+func (b *backend) List(_ context.Context, gr schema.GroupResource, namespace string) (map[storage.Key]storage.VersionedObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make(map[storage.Key]storage.VersionedObject)
+	for k, v := range b.objects {
+		if k.GroupResource != gr {
+			continue
+		}
+		if namespace != "" && k.Namespace != namespace {
+			continue
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (b *backend) Create(_ context.Context, key storage.Key, data []byte) (string, error) {
+	b.mu.Lock()
+	if _, ok := b.objects[key]; ok {
+		b.mu.Unlock()
+		return "", storage.ErrAlreadyExists
+	}
+	rv := b.nextResourceVersion()
+	b.objects[key] = storage.VersionedObject{Data: data, ResourceVersion: rv}
+	b.mu.Unlock()
+
+	b.cache.Push(storage.Event{Type: storage.Added, Key: key, Data: data, ResourceVersion: rv})
+	return rv, nil
+}
+
+func (b *backend) Update(_ context.Context, key storage.Key, data []byte, expectedResourceVersion string) (string, error) {
+	b.mu.Lock()
+	existing, ok := b.objects[key]
+	if !ok {
+		b.mu.Unlock()
+		return "", storage.ErrNotFound
+	}
+	if expectedResourceVersion != "" && expectedResourceVersion != existing.ResourceVersion {
+		b.mu.Unlock()
+		return "", storage.ErrConflict
+	}
+	rv := b.nextResourceVersion()
+	b.objects[key] = storage.VersionedObject{Data: data, ResourceVersion: rv}
+	b.mu.Unlock()
+
+	b.cache.Push(storage.Event{Type: storage.Modified, Key: key, Data: data, ResourceVersion: rv})
+	return rv, nil
+}
+
+func (b *backend) Delete(_ context.Context, key storage.Key, expectedResourceVersion string) (storage.VersionedObject, error) {
+	b.mu.Lock()
+	existing, ok := b.objects[key]
+	if !ok {
+		b.mu.Unlock()
+		return storage.VersionedObject{}, storage.ErrNotFound
+	}
+	if expectedResourceVersion != "" && expectedResourceVersion != existing.ResourceVersion {
+		b.mu.Unlock()
+		return storage.VersionedObject{}, storage.ErrConflict
+	}
+	delete(b.objects, key)
+	b.mu.Unlock()
+
+	b.cache.Push(storage.Event{Type: storage.Deleted, Key: key, Data: existing.Data, ResourceVersion: existing.ResourceVersion})
+	return existing, nil
+}
+
+func (b *backend) Watch(_ context.Context, gr schema.GroupResource, namespace string, sinceResourceVersion string) (storage.Watcher, error) {
+	return b.cache.Watch(gr, namespace, sinceResourceVersion)
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/henderiw/apiserver-runtime-example/pkg/storage"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var testGR = schema.GroupResource{Group: "example.com", Resource: "widgets"}
+
+func TestBackendCreateGetListDelete(t *testing.T) {
+	ctx := context.Background()
+	b := New()
+	key := storage.Key{GroupResource: testGR, Namespace: "ns", Name: "a"}
+
+	if _, err := b.Create(ctx, key, []byte("v1")); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := b.Create(ctx, key, []byte("v1")); err != storage.ErrAlreadyExists {
+		t.Fatalf("expected ErrAlreadyExists on duplicate Create, got %v", err)
+	}
+
+	got, err := b.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(got.Data) != "v1" {
+		t.Fatalf("expected data %q, got %q", "v1", got.Data)
+	}
+
+	list, err := b.List(ctx, testGR, "ns")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(list))
+	}
+	if _, err := b.Delete(ctx, key, ""); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := b.Get(ctx, key); err != storage.ErrNotFound {
+		t.Fatalf("expected ErrNotFound after Delete, got %v", err)
+	}
+}
+
+func TestBackendUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	b := New()
+	key := storage.Key{GroupResource: testGR, Name: "a"}
+
+	rv, err := b.Create(ctx, key, []byte("v1"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := b.Update(ctx, key, []byte("v2"), "not-"+rv); err != storage.ErrConflict {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if _, err := b.Update(ctx, key, []byte("v2"), rv); err != nil {
+		t.Fatalf("Update with correct resourceVersion: %v", err)
+	}
+}
+
+func TestBackendWatchLive(t *testing.T) {
+	ctx := context.Background()
+	b := New()
+	key := storage.Key{GroupResource: testGR, Name: "a"}
+
+	rv, err := b.Create(ctx, key, []byte("v1"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	w, err := b.Watch(ctx, testGR, "", "")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer w.Stop()
+
+	if _, err := b.Update(ctx, key, []byte("v2"), rv); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	ev := <-w.ResultChan()
+	if ev.Type != storage.Modified {
+		t.Fatalf("expected a Modified event, got %v", ev.Type)
+	}
+}
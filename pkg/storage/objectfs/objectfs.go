@@ -0,0 +1,46 @@
+// Package objectfs provides ready-made afero.Fs constructors for the disk
+// storage.Backend, covering the filesystems operators actually reach for:
+// the real OS filesystem, an in-memory filesystem for tests, and an
+// S3-compatible object store for running on read-only root filesystems or
+// ephemeral pods.
+package objectfs
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	s3fs "github.com/fclairamb/afero-s3"
+	"github.com/spf13/afero"
+)
+
+// OS returns the default afero.Fs, backed by the real operating system
+// filesystem.
+func OS() afero.Fs {
+	return afero.NewOsFs()
+}
+
+// Memory returns an afero.Fs that keeps everything in memory. Useful for
+// unit tests that need a disk storage.Backend without touching disk.
+func Memory() afero.Fs {
+	return afero.NewMemMapFs()
+}
+
+// S3Config carries the bucket and client configuration needed to host CR
+// state on an S3 (or S3-compatible, e.g. MinIO/GCS-via-S3-gateway) bucket.
+type S3Config struct {
+	Bucket   string
+	Region   string
+	Endpoint string
+}
+
+// S3 returns an afero.Fs backed by the given S3 bucket, so operators can run
+// the apiserver with no persistent local disk at all.
+func S3(cfg S3Config) (afero.Fs, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:   aws.String(cfg.Region),
+		Endpoint: aws.String(cfg.Endpoint),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s3fs.NewFs(cfg.Bucket, sess), nil
+}
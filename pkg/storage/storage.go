@@ -0,0 +1,102 @@
+// Package storage defines the pluggable persistence interface used by the
+// filepath REST storage. A Backend stores and streams the codec-encoded
+// bytes of a single object; it has no knowledge of the runtime.Object it is
+// holding, which is what lets the same interface be backed by a single JSON
+// file on disk, a BoltDB bucket, an in-memory map, or an etcd keyspace.
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrNotFound is returned by Get/Update/Delete when the requested key has no
+// stored object.
+var ErrNotFound = errors.New("storage: key not found")
+
+// ErrAlreadyExists is returned by Create when the key is already populated.
+var ErrAlreadyExists = errors.New("storage: key already exists")
+
+// ErrConflict is returned by Update/Delete when a non-empty expected
+// resourceVersion doesn't match the one currently stored for the key.
+var ErrConflict = errors.New("storage: resource version conflict")
+
+// ErrResourceVersionTooOld is returned by Watch when the requested
+// resourceVersion has already fallen out of the backend's retained history,
+// mirroring upstream kube-apiserver's "410 Gone" relist signal.
+var ErrResourceVersionTooOld = errors.New("storage: resource version too old, relist required")
+
+// Key identifies a single object within a Backend.
+type Key struct {
+	GroupResource schema.GroupResource
+	Namespace     string
+	Name          string
+}
+
+// VersionedObject pairs an object's still-encoded bytes with the
+// resourceVersion the Backend assigned it on the last write.
+type VersionedObject struct {
+	Data            []byte
+	ResourceVersion string
+}
+
+// EventType mirrors k8s.io/apimachinery/pkg/watch.EventType for the raw
+// events a Backend emits.
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+	// Bookmark carries no object, only a resourceVersion: a checkpoint a
+	// long-lived watcher can safely resume from without seeing any traffic.
+	Bookmark EventType = "BOOKMARK"
+)
+
+// Event is a single mutation of a stored object, carrying its still-encoded
+// bytes and resulting resourceVersion so the caller can decode with whatever
+// codec it was written with and stamp metadata.resourceVersion accordingly.
+// A Bookmark event has a zero Key and nil Data.
+type Event struct {
+	Type            EventType
+	Key             Key
+	Data            []byte
+	ResourceVersion string
+}
+
+// Watcher streams Events for a Backend.Watch subscription. Callers must call
+// Stop when done to release the subscription.
+type Watcher interface {
+	ResultChan() <-chan Event
+	Stop()
+}
+
+// Backend is the interface a storage driver must implement to back a
+// filepathREST (or the generic file[T1] store) with concrete persistence.
+// Get/List/Create/Update/Delete operate on the raw, already-encoded bytes of
+// a single object, keyed by GroupResource plus namespace/name; Watch streams
+// every subsequent mutation for a GroupResource (optionally scoped to a
+// namespace).
+//
+// Every successful write is assigned a resourceVersion by the Backend.
+// Update and Delete accept an expectedResourceVersion: an empty string skips
+// the check (last-write-wins), a non-empty value that doesn't match the
+// currently stored resourceVersion fails with ErrConflict. This is what lets
+// filepathREST enforce optimistic concurrency the way upstream kube-apiserver
+// does.
+type Backend interface {
+	Get(ctx context.Context, key Key) (VersionedObject, error)
+	List(ctx context.Context, gr schema.GroupResource, namespace string) (map[Key]VersionedObject, error)
+	Create(ctx context.Context, key Key, data []byte) (resourceVersion string, err error)
+	Update(ctx context.Context, key Key, data []byte, expectedResourceVersion string) (resourceVersion string, err error)
+	Delete(ctx context.Context, key Key, expectedResourceVersion string) (VersionedObject, error)
+	// Watch streams mutations for a GroupResource (optionally scoped to a
+	// namespace). An empty sinceResourceVersion starts a purely live
+	// subscription; a non-empty one first replays every retained event with
+	// a resourceVersion greater than it before switching to live delivery,
+	// returning ErrResourceVersionTooOld if it has already aged out of the
+	// backend's retained history.
+	Watch(ctx context.Context, gr schema.GroupResource, namespace string, sinceResourceVersion string) (Watcher, error)
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DefaultWatchCacheCapacity is the number of recent events a WatchCache
+// retains for replay, matching the "say 1000 events" sizing upstream
+// kube-apiserver's watch cache typically uses for a single resource.
+const DefaultWatchCacheCapacity = 1000
+
+// WatchCache is a bounded ring buffer of recent Events plus the set of live
+// subscribers, shared by the in-process Backend implementations (memory,
+// disk, boltdb). It lets Watch(sinceResourceVersion) replay everything that
+// happened while a client was disconnected instead of only ever seeing
+// events from the moment it (re)connects, and it emits periodic Bookmark
+// events so long-lived watchers can advance their checkpoint without
+// traffic.
+//
+// A single mutex guards both the event ring buffer and the watchers map:
+// Watch takes its replay snapshot and registers the new subscriber as one
+// atomic step, so no event pushed in between can be lost, and Push delivers
+// to subscribers while still holding the lock that would let a new Watch
+// slip in unseen.
+type WatchCache struct {
+	mu       sync.Mutex
+	capacity int
+	events   []Event
+	oldestRV int64
+	latestRV int64
+	nextID   int
+	watchers map[int]*cacheWatcher
+
+	stopBookmarks chan struct{}
+}
+
+// NewWatchCache returns a WatchCache retaining up to capacity events. If
+// bookmarkInterval is non-zero, a Bookmark event carrying the latest
+// resourceVersion is broadcast to all subscribers on that interval.
+func NewWatchCache(capacity int, bookmarkInterval time.Duration) *WatchCache {
+	if capacity <= 0 {
+		capacity = DefaultWatchCacheCapacity
+	}
+	c := &WatchCache{
+		capacity: capacity,
+		watchers: make(map[int]*cacheWatcher),
+	}
+	if bookmarkInterval > 0 {
+		c.stopBookmarks = make(chan struct{})
+		go c.runBookmarks(bookmarkInterval)
+	}
+	return c
+}
+
+func (c *WatchCache) runBookmarks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			rv := c.latestRV
+			if rv != 0 {
+				c.broadcastLocked(Event{Type: Bookmark, ResourceVersion: strconv.FormatInt(rv, 10)})
+			}
+			c.mu.Unlock()
+		case <-c.stopBookmarks:
+			return
+		}
+	}
+}
+
+// Close stops the periodic bookmark goroutine, if any.
+func (c *WatchCache) Close() {
+	if c.stopBookmarks != nil {
+		close(c.stopBookmarks)
+	}
+}
+
+// Push records ev in the ring buffer and delivers it to every matching live
+// subscriber.
+func (c *WatchCache) Push(ev Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rv, err := strconv.ParseInt(ev.ResourceVersion, 10, 64); err == nil {
+		c.events = append(c.events, ev)
+		if len(c.events) > c.capacity {
+			c.events = c.events[len(c.events)-c.capacity:]
+		}
+		c.oldestRV, _ = strconv.ParseInt(c.events[0].ResourceVersion, 10, 64)
+		c.latestRV = rv
+	}
+	c.broadcastLocked(ev)
+}
+
+// ActiveWatchers returns the number of currently registered subscriptions,
+// for callers that want to surface it as a gauge.
+func (c *WatchCache) ActiveWatchers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.watchers)
+}
+
+// broadcastLocked delivers ev to every matching watcher. Callers must hold
+// c.mu. The send to each watcher's channel is non-blocking: a watcher whose
+// relay loop has fallen behind (or stopped reading) gets ev dropped rather
+// than stalling every other watcher, and every other backend operation,
+// behind it for as long as c.mu is held. Callers that need to know about a
+// drop (to surface it as a metric, for instance) are expected to detect the
+// gap themselves on reconnect via sinceResourceVersion, the same way a
+// client recovers from missing any other watch event.
+func (c *WatchCache) broadcastLocked(ev Event) {
+	for _, w := range c.watchers {
+		if ev.Type != Bookmark {
+			if w.gr != ev.Key.GroupResource {
+				continue
+			}
+			if w.namespace != "" && w.namespace != ev.Key.Namespace {
+				continue
+			}
+		}
+		select {
+		case w.ch <- ev:
+		default:
+		}
+	}
+}
+
+// Watch registers a subscription for gr (optionally scoped to namespace). An
+// empty sinceResourceVersion starts a live-only subscription; a non-empty
+// one replays every retained event with resourceVersion > since before the
+// returned Watcher starts delivering live events, or fails with
+// ErrResourceVersionTooOld if since is older than everything still retained.
+func (c *WatchCache) Watch(gr schema.GroupResource, namespace, sinceResourceVersion string) (Watcher, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var replay []Event
+	if sinceResourceVersion != "" {
+		since, err := strconv.ParseInt(sinceResourceVersion, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if since > 0 && since < c.oldestRV && len(c.events) > 0 {
+			return nil, ErrResourceVersionTooOld
+		}
+		for _, ev := range c.events {
+			rv, err := strconv.ParseInt(ev.ResourceVersion, 10, 64)
+			if err != nil || rv <= since {
+				continue
+			}
+			if ev.Key.GroupResource != gr {
+				continue
+			}
+			if namespace != "" && ev.Key.Namespace != namespace {
+				continue
+			}
+			replay = append(replay, ev)
+		}
+	}
+
+	id := c.nextID
+	c.nextID++
+	w := &cacheWatcher{
+		id:        id,
+		gr:        gr,
+		namespace: namespace,
+		ch:        make(chan Event, 10+len(replay)),
+	}
+	w.stop = func() {
+		c.mu.Lock()
+		delete(c.watchers, id)
+		close(w.ch)
+		c.mu.Unlock()
+	}
+	c.watchers[id] = w
+
+	// Registering the watcher happened under the same lock as the replay
+	// snapshot above, so nothing Pushed in between is missed or duplicated;
+	// sending the replay here, still under the lock, guarantees it's
+	// delivered before any live event Push could enqueue behind it.
+	for _, ev := range replay {
+		w.ch <- ev
+	}
+	return w, nil
+}
+
+type cacheWatcher struct {
+	id        int
+	gr        schema.GroupResource
+	namespace string
+	ch        chan Event
+	stop      func()
+	stopOnce  sync.Once
+}
+
+func (w *cacheWatcher) ResultChan() <-chan Event {
+	return w.ch
+}
+
+// Stop unregisters the watcher and closes its channel so relay loops ranging
+// over ResultChan() terminate instead of blocking forever once the watcher
+// is unreachable. Guarded by sync.Once since callers may Stop more than once.
+func (w *cacheWatcher) Stop() {
+	w.stopOnce.Do(w.stop)
+}
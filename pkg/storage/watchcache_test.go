@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var testGR = schema.GroupResource{Group: "example.com", Resource: "widgets"}
+
+func TestWatchCacheStopClosesChannel(t *testing.T) {
+	c := NewWatchCache(10, 0)
+	w, err := c.Watch(testGR, "", "")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	w.Stop()
+
+	select {
+	case _, ok := <-w.ResultChan():
+		if ok {
+			t.Fatalf("expected closed channel, got a value")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("ResultChan did not close after Stop, relay loops would leak")
+	}
+
+	// Stop must be safe to call more than once.
+	w.Stop()
+}
+
+func TestWatchCacheReplayThenLiveNoGap(t *testing.T) {
+	c := NewWatchCache(10, 0)
+
+	c.Push(Event{Type: Added, Key: Key{GroupResource: testGR, Name: "a"}, ResourceVersion: "1"})
+	c.Push(Event{Type: Added, Key: Key{GroupResource: testGR, Name: "b"}, ResourceVersion: "2"})
+
+	w, err := c.Watch(testGR, "", "1")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer w.Stop()
+
+	c.Push(Event{Type: Added, Key: Key{GroupResource: testGR, Name: "c"}, ResourceVersion: "3"})
+
+	var got []string
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-w.ResultChan():
+			got = append(got, ev.Key.Name)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+	if got[0] != "b" || got[1] != "c" {
+		t.Fatalf("expected replay(b) then live(c) in order, got %v", got)
+	}
+}
+
+func TestWatchCacheResourceVersionTooOld(t *testing.T) {
+	c := NewWatchCache(1, 0)
+	c.Push(Event{Type: Added, Key: Key{GroupResource: testGR, Name: "a"}, ResourceVersion: "1"})
+	c.Push(Event{Type: Added, Key: Key{GroupResource: testGR, Name: "b"}, ResourceVersion: "2"})
+
+	_, err := c.Watch(testGR, "", "1")
+	if err != ErrResourceVersionTooOld {
+		t.Fatalf("expected ErrResourceVersionTooOld, got %v", err)
+	}
+}
+
+func TestWatchCachePushDoesNotBlockOnSlowWatcher(t *testing.T) {
+	c := NewWatchCache(10, 0)
+
+	slow, err := c.Watch(testGR, "", "")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer slow.Stop()
+	fast, err := c.Watch(testGR, "", "")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer fast.Stop()
+
+	// fast is drained continuously; slow never is, so its buffered channel
+	// fills up. Push must still return promptly and fast must still receive
+	// every event, instead of Push blocking on slow once slow's buffer is full.
+	const total = 12
+	received := make(chan struct{}, total)
+	go func() {
+		for range fast.ResultChan() {
+			received <- struct{}{}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < total; i++ {
+			c.Push(Event{Type: Added, Key: Key{GroupResource: testGR, Name: "x"}, ResourceVersion: strconv.Itoa(i + 1)})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Push blocked on a slow watcher instead of dropping the event")
+	}
+
+	for i := 0; i < total; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("fast watcher missed event %d", i)
+		}
+	}
+}
+
+func TestWatchCacheActiveWatchers(t *testing.T) {
+	c := NewWatchCache(10, 0)
+	if n := c.ActiveWatchers(); n != 0 {
+		t.Fatalf("expected 0 active watchers, got %d", n)
+	}
+	w, err := c.Watch(testGR, "", "")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if n := c.ActiveWatchers(); n != 1 {
+		t.Fatalf("expected 1 active watcher, got %d", n)
+	}
+	w.Stop()
+	if n := c.ActiveWatchers(); n != 0 {
+		t.Fatalf("expected 0 active watchers after Stop, got %d", n)
+	}
+}
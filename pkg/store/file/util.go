@@ -24,10 +24,16 @@ import (
 	"strings"
 
 	"github.com/henderiw/apiserver-runtime-example/pkg/store"
+	"github.com/spf13/afero"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
 )
 
+// The helpers below read r.fs (an afero.Fs set by the file[T1] constructor,
+// afero.NewOsFs() by default) instead of calling the os package directly, so
+// callers can swap in an in-memory or object-storage-backed filesystem.
+
 func (r *file[T1]) filename(key store.Key) string {
 	if key.Namespace != "" {
 		return filepath.Join(r.objRootPath, key.Namespace, key.Name+".json")
@@ -36,20 +42,24 @@ func (r *file[T1]) filename(key store.Key) string {
 }
 
 func (r *file[T1]) readFile(ctx context.Context, key store.Key) (T1, error) {
+	log := klog.FromContext(ctx).WithValues("key", key, "file", r.filename(key))
 	var obj T1
-	content, err := os.ReadFile(filepath.Clean(r.filename(key)))
+	content, err := afero.ReadFile(r.fs, filepath.Clean(r.filename(key)))
 	if err != nil {
+		log.Error(err, "read failed")
 		return obj, err
 	}
 	newObj := r.newFunc()
 	decodeObj, _, err := r.codec.Decode(content, nil, newObj)
 	if err != nil {
+		log.Error(err, "decode failed")
 		return obj, err
 	}
 	obj, ok := decodeObj.(T1)
 	if !ok {
 		return obj, fmt.Errorf("unexpected object, got: %s", reflect.TypeOf(decodeObj).Name())
 	}
+	log.V(4).Info("read")
 	return obj, nil
 }
 
@@ -62,25 +72,42 @@ func convert(obj any) (runtime.Object, error) {
 }
 
 func (r *file[T1]) writeFile(ctx context.Context, key store.Key, obj T1) error {
+	log := klog.FromContext(ctx).WithValues("key", key, "file", r.filename(key))
 	runtimeObj, err := convert(obj)
 	if err != nil {
+		log.Error(err, "write failed")
 		return err
 	}
 
 	buf := new(bytes.Buffer)
 	if err := r.codec.Encode(runtimeObj, buf); err != nil {
+		log.Error(err, "encode failed")
+		return err
+	}
+	if err := afero.WriteFile(r.fs, r.filename(key), buf.Bytes(), 0600); err != nil {
+		log.Error(err, "write failed")
 		return err
 	}
-	return os.WriteFile(r.filename(key), buf.Bytes(), 0600)
+	log.V(4).Info("write")
+	return nil
 }
 
 func (r *file[T1]) deleteFile(ctx context.Context, key store.Key) error {
-	return os.Remove(r.filename(key))
+	log := klog.FromContext(ctx).WithValues("key", key, "file", r.filename(key))
+	if err := r.fs.Remove(r.filename(key)); err != nil {
+		log.Error(err, "delete failed")
+		return err
+	}
+	log.V(4).Info("delete")
+	return nil
 }
 
 func (r *file[T1]) visitDir(ctx context.Context, visitorFunc func(ctx context.Context, key store.Key, obj T1)) error {
-	return filepath.Walk(r.objRootPath, func(path string, info os.FileInfo, err error) error {
+	log := klog.FromContext(ctx).WithValues("root", r.objRootPath)
+	log.V(4).Info("visitDir")
+	return afero.Walk(r.fs, r.objRootPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
+			log.Error(err, "walk failed", "path", path)
 			return err
 		}
 		if info.IsDir() {
@@ -121,14 +148,14 @@ func (r *file[T1]) visitDir(ctx context.Context, visitorFunc func(ctx context.Co
 	})
 }
 
-func exists(filepath string) bool {
-	_, err := os.Stat(filepath)
+func (r *file[T1]) exists(filepath string) bool {
+	_, err := r.fs.Stat(filepath)
 	return err == nil
 }
 
-func ensureDir(dirname string) error {
-	if !exists(dirname) {
-		return os.MkdirAll(dirname, 0700)
+func (r *file[T1]) ensureDir(dirname string) error {
+	if !r.exists(dirname) {
+		return r.fs.MkdirAll(dirname, 0700)
 	}
 	return nil
 }
\ No newline at end of file